@@ -2,24 +2,280 @@ package slogger
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
 type Appender interface {
 	Append(log *Log) error
 }
 
+// Syncer is implemented by appenders that can commit what they have
+// written to stable storage, e.g. by calling `Sync` on an `*os.File`.
+type Syncer interface {
+	Sync() error
+}
+
+// AppenderFunc adapts an ordinary function to the Appender interface.
+type AppenderFunc func(log *Log) error
+
+func (self AppenderFunc) Append(log *Log) error {
+	return self(log)
+}
+
+func (self AppenderFunc) Close() error {
+	return nil
+}
+
+const DefaultTimestampLayout = "2006/01/02 15:04:05"
+
+// The timestamp layout used by `FormatLog`, e.g.
+// "2006-01-02T15:04:05.000Z07:00" for RFC3339 with milliseconds. Set it
+// during initialization, before anything logs.
+var TimestampLayout = DefaultTimestampLayout
+
+// When set, `FormatLog` renders `TimestampTransform(log.Timestamp)`
+// instead of the log's timestamp, e.g. to round it to the second or
+// pin it for golden-output tests. Set it during initialization, before
+// anything logs.
+var TimestampTransform func(timestamp time.Time) time.Time
+
+// When set, `FormatLog` follows the timestamp with the time elapsed
+// since `ElapsedSince`, e.g. `[2013/03/07 14:05:09] [+00:01:23.456]`
+// for profiling. Set it during initialization, usually to `time.Now()`.
+var ElapsedSince time.Time
+
+// Whether `FormatLog` replaces invalid UTF-8 in a line with U+FFFD,
+// so that log files are always valid UTF-8. Set it to false to pass
+// legacy bytes through untouched. Set it during initialization, before
+// anything logs.
+var SanitizeUTF8 = true
+
 func FormatLog(log *Log) string {
-	year, month, day := log.Timestamp.Date()
-	hour, min, sec := log.Timestamp.Clock()
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer putBuffer(buffer)
+
+	buffer.Reset()
+	FormatLogTo(buffer, log)
+	return buffer.String()
+}
+
+// Write the `FormatLog` rendering of `log` to `writer`. The log may be
+// written in more than one call; buffer it first if the line must land
+// in a single write.
+func FormatLogTo(writer io.Writer, log *Log) error {
+	return formatLogTo(writer, log, FormatLevel(log.Level))
+}
+
+// `FormatLogTo` with the level rendered as `level`.
+func formatLogTo(writer io.Writer, log *Log, level string) error {
+	return formatLineTo(writer, log, level, true)
+}
+
+// Everything `formatLogTo` writes except the timestamp, for
+// destinations such as syslog that record their own.
+func formatLogBodyTo(writer io.Writer, log *Log, level string) error {
+	return formatLineTo(writer, log, level, false)
+}
+
+// A level with an entry in `LevelLabels` leads the line; otherwise the
+// level follows the prefix.
+func formatLineTo(writer io.Writer, log *Log, level string, timestamp bool) error {
+	if SanitizeUTF8 {
+		writer = utf8Writer{writer}
+	}
+
+	_, labeled := LevelLabels[log.Level]
+	if labeled {
+		if _, err := fmt.Fprintf(writer, "[%v] ", level); err != nil {
+			return err
+		}
+	}
+
+	if timestamp {
+		rendered := log.Timestamp
+		if TimestampTransform != nil {
+			rendered = TimestampTransform(rendered)
+		}
+
+		_, err := fmt.Fprintf(writer, "[%v] ", FormatTimestamp(rendered, TimestampLayout))
+		if err != nil {
+			return err
+		}
+
+		if ElapsedSince.IsZero() == false {
+			_, err = fmt.Fprintf(writer, "[%v] ", FormatElapsed(rendered.Sub(ElapsedSince)))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var err error
+	caller := FormatCaller(log.Filename, log.Line)
+	if labeled {
+		_, err = fmt.Fprintf(writer, "[%v] [%v] ", log.Prefix, caller)
+	} else {
+		_, err = fmt.Fprintf(writer, "[%v.%v] [%v] ", log.Prefix, level, caller)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(writer, log.messageFmt, log.args...); err != nil {
+		return err
+	}
+
+	if len(log.Fields) > 0 {
+		if _, err = io.WriteString(writer, " "+FormatFields(log.Fields)); err != nil {
+			return err
+		}
+	}
+
+	for _, frame := range log.Stacktrace {
+		if _, err = io.WriteString(writer, "\n\t"+frame); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(writer, "\n")
+	return err
+}
+
+// Every write of the format path is a complete element of the line,
+// so a sequence split across writes is invalid either way. Valid input,
+// the common case, is passed through without copying.
+type utf8Writer struct {
+	io.Writer
+}
+
+func (self utf8Writer) Write(data []byte) (int, error) {
+	if utf8.Valid(data) {
+		return self.Writer.Write(data)
+	}
+
+	if _, err := self.Writer.Write(bytes.ToValidUTF8(data, []byte("\uFFFD"))); err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+func (self utf8Writer) WriteString(str string) (int, error) {
+	if utf8.ValidString(str) {
+		return io.WriteString(self.Writer, str)
+	}
+
+	if _, err := io.WriteString(self.Writer, strings.ToValidUTF8(str, "\uFFFD")); err != nil {
+		return 0, err
+	}
+
+	return len(str), nil
+}
+
+// Buffers reused by the format path to spare an allocation per log.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// Buffers grown past this by an unusually large log are dropped rather
+// than kept for every later log. fmt uses the same limit.
+const maxPooledBuffer = 64 << 10
+
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBuffer {
+		return
+	}
+
+	bufferPool.Put(buffer)
+}
+
+// The helpers below are the building blocks of `FormatLog`. Custom
+// formatters can compose them to stay consistent with the default
+// output.
+
+// Labels rendered at the start of the line in place of level names,
+// e.g. `LevelLabels = ShortLevelLabels` for lines like
+// `[E] [2013/03/07 14:05:09] [agent] ...`. Levels without a label
+// render their name after the prefix as usual. Set it during
+// initialization, before anything logs.
+var LevelLabels map[Level]string
+
+// Single-letter labels for the built-in levels.
+var ShortLevelLabels = map[Level]string{
+	DEBUG: "D",
+	INFO:  "I",
+	WARN:  "W",
+	ERROR: "E",
+}
+
+func FormatLevel(level Level) string {
+	if label, exists := LevelLabels[level]; exists {
+		return label
+	}
 
-	return fmt.Sprintf("[%.4d/%.2d/%.2d %.2d:%.2d:%.2d] [%v.%v] [%v:%d] %v\n",
-		year, month, day,
-		hour, min, sec,
-		log.Prefix, log.Level.Type(),
-		log.Filename, log.Line,
-		log.Message())
+	return level.Type()
+}
+
+func FormatCaller(file string, line int) string {
+	return fmt.Sprintf("%v:%d", file, line)
+}
+
+func FormatTimestamp(timestamp time.Time, layout string) string {
+	return timestamp.Format(layout)
+}
+
+// Render a duration as a signed `hours:minutes:seconds.milliseconds`,
+// e.g. "+00:01:23.456".
+func FormatElapsed(elapsed time.Duration) string {
+	sign := "+"
+	if elapsed < 0 {
+		sign = "-"
+		elapsed = -elapsed
+	}
+
+	millis := elapsed / time.Millisecond
+	return fmt.Sprintf("%v%02d:%02d:%02d.%03d", sign,
+		millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
+
+// Render fields as space separated `key=value` pairs sorted by key.
+// Keys and values that are empty or contain spaces, quotes or `=` are
+// quoted, so the pairs can be parsed back.
+func FormatFields(fields Fields) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, quoteField(key)+"="+quoteField(fmt.Sprint(fields[key])))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func quoteField(str string) string {
+	if str == "" || strings.ContainsAny(str, " \t\n\"=") {
+		return strconv.Quote(str)
+	}
+
+	return str
 }
 
 type WriteStringer interface {
@@ -31,11 +287,82 @@ type FileAppender struct {
 	WriteStringer
 }
 
+// The log is formatted into a pooled buffer and written with a single
+// call. If the underlying WriteStringer is also an `io.Writer`, the
+// buffer's bytes are written without converting them to a string.
 func (self FileAppender) Append(log *Log) error {
-	_, err := self.WriteString(FormatLog(log))
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer putBuffer(buffer)
+
+	buffer.Reset()
+	FormatLogTo(buffer, log)
+	if writer, ok := self.WriteStringer.(io.Writer); ok {
+		_, err := writer.Write(buffer.Bytes())
+		return err
+	}
+
+	_, err := self.WriteString(buffer.String())
 	return err
 }
 
+// Sync the underlying WriteStringer if it implements `Syncer`.
+func (self FileAppender) Sync() error {
+	return syncWriter(self.WriteStringer)
+}
+
+// Terminals and pipes such as os.Stdout cannot be synced and have
+// nothing to make durable, so their EINVAL is not an error.
+func syncWriter(writer interface{}) error {
+	syncer, ok := writer.(Syncer)
+	if ok == false {
+		return nil
+	}
+
+	if err := syncer.Sync(); err != nil && errors.Is(err, syscall.EINVAL) == false {
+		return err
+	}
+
+	return nil
+}
+
+// WriterAppender is a `FileAppender` that owns its writer: `Close`
+// closes the writer if it implements `io.Closer`.
+type WriterAppender struct {
+	FileAppender
+	writer io.Writer
+}
+
+// Return an appender writing to any `io.Writer`, e.g. a `net.Conn`.
+func NewWriterAppender(writer io.Writer) *WriterAppender {
+	if writeStringer, ok := writer.(WriteStringer); ok {
+		return &WriterAppender{FileAppender{writeStringer}, writer}
+	}
+
+	return &WriterAppender{FileAppender{stringWriter{writer}}, writer}
+}
+
+func (self *WriterAppender) Sync() error {
+	return syncWriter(self.writer)
+}
+
+func (self *WriterAppender) Close() error {
+	if closer, ok := self.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+type stringWriter struct {
+	io.Writer
+}
+
+func (self stringWriter) WriteString(str string) (int, error) {
+	return self.Write([]byte(str))
+}
+
+// The standard stream appenders are plain `FileAppender`s, which have
+// no `Close`, so closing a wrapping appender leaves the streams open.
 func StdOutAppender() *FileAppender {
 	return &FileAppender{os.Stdout}
 }
@@ -53,6 +380,82 @@ func DevNullAppender() (*FileAppender, error) {
 	return &FileAppender{devNull}, nil
 }
 
+// NullAppender discards every log. Unlike `DevNullAppender` it opens
+// no file, making it a cheap stand-in for tests or for turning logging
+// off through configuration.
+type NullAppender struct{}
+
+var _ Appender = NullAppender{}
+
+func (self NullAppender) Append(log *Log) error {
+	return nil
+}
+
+func (self NullAppender) Close() error {
+	return nil
+}
+
+type ColorMode uint8
+
+const (
+	// Color only when writing to a terminal. Terminals are detected on
+	// Linux, macOS and the BSDs; elsewhere ColorAuto never colors.
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+const (
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiReset  = "\x1b[0m"
+)
+
+// ConsoleAppender writes `FormatLog` output with the level of WARN logs
+// in yellow and of ERROR logs in red.
+type ConsoleAppender struct {
+	writer io.Writer
+	color  bool
+}
+
+func NewConsoleAppender(writer io.Writer, mode ColorMode) *ConsoleAppender {
+	return &ConsoleAppender{
+		writer: writer,
+		color:  mode == ColorAlways || (mode == ColorAuto && isTerminal(writer)),
+	}
+}
+
+func StdErrConsoleAppender() *ConsoleAppender {
+	return NewConsoleAppender(os.Stderr, ColorAuto)
+}
+
+func (self *ConsoleAppender) Append(log *Log) error {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer putBuffer(buffer)
+
+	level := FormatLevel(log.Level)
+	if self.color {
+		switch {
+		case log.Level >= ERROR:
+			level = ansiRed + level + ansiReset
+		case log.Level >= WARN:
+			level = ansiYellow + level + ansiReset
+		}
+	}
+
+	buffer.Reset()
+	formatLogTo(buffer, log, level)
+	_, err := self.writer.Write(buffer.Bytes())
+	return err
+}
+
+// Only an `*os.File` backed by a terminal qualifies; other character
+// devices such as /dev/null do not.
+func isTerminal(writer io.Writer) bool {
+	file, ok := writer.(*os.File)
+	return ok && isTerminalFd(file.Fd())
+}
+
 type StringAppender struct {
 	*bytes.Buffer
 }
@@ -62,10 +465,272 @@ func NewStringAppender(buffer *bytes.Buffer) *StringAppender {
 }
 
 func (self StringAppender) Append(log *Log) error {
-	_, err := self.WriteString(FormatLog(log) + "\n")
+	if err := FormatLogTo(self.Buffer, log); err != nil {
+		return err
+	}
+
+	_, err := self.WriteString("\n")
 	return err
 }
 
+// MultiAppender forwards every log to each of its appenders. A failing
+// appender does not stop the remaining ones from receiving the log;
+// all errors are returned together.
+type MultiAppender struct {
+	Appenders []Appender
+}
+
+func NewMultiAppender(appenders ...Appender) *MultiAppender {
+	return &MultiAppender{appenders}
+}
+
+func (self *MultiAppender) Append(log *Log) error {
+	var errs []error
+	for _, appender := range self.Appenders {
+		if err := appender.Append(log); err != nil {
+			errs = append(errs, fmt.Errorf("Error appending. Appender: %T Error: %v", appender, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close every appender that implements `io.Closer`.
+func (self *MultiAppender) Close() error {
+	return closeAppenders(self.Appenders)
+}
+
+// Sync every appender that implements `Syncer`, so that every child
+// has committed the logs appended so far.
+func (self *MultiAppender) Sync() error {
+	return syncAppenders(self.Appenders)
+}
+
+func syncAppenders(appenders []Appender) error {
+	var errs []error
+	for _, appender := range appenders {
+		if syncer, ok := appender.(Syncer); ok {
+			if err := syncer.Sync(); err != nil {
+				errs = append(errs, fmt.Errorf("Error syncing. Appender: %T Error: %v", appender, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func closeAppenders(appenders []Appender) error {
+	var errs []error
+	for _, appender := range appenders {
+		if closer, ok := appender.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("Error closing. Appender: %T Error: %v", appender, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Routes logs with a level in [`Min`, `Max`] to `Appender`. `Min`
+// follows `LevelFilter`: OFF, its zero value, matches nothing and ALL
+// matches everything. Leaving `Max` unset, or setting it to ALL, leaves
+// the range unbounded above.
+type LevelRoute struct {
+	Min      Level
+	Max      Level
+	Appender Appender
+}
+
+func (self LevelRoute) Matches(level Level) bool {
+	if self.Min == OFF {
+		return false
+	}
+
+	unbounded := self.Max == 0 || self.Max == ALL
+	return level >= self.Min && (unbounded || level <= self.Max)
+}
+
+// LevelRoutingAppender dispatches each log to the appenders of every
+// route matching its level, e.g. DEBUG and INFO to one file and WARN
+// and above to another:
+//
+//	slogger.NewLevelRoutingAppender(
+//	    slogger.LevelRoute{Min: slogger.DEBUG, Max: slogger.INFO, Appender: appLog},
+//	    slogger.LevelRoute{Min: slogger.WARN, Appender: errorLog})
+//
+// Logs matching no route are dropped.
+type LevelRoutingAppender struct {
+	Routes []LevelRoute
+}
+
+func NewLevelRoutingAppender(routes ...LevelRoute) *LevelRoutingAppender {
+	return &LevelRoutingAppender{routes}
+}
+
+func (self *LevelRoutingAppender) Append(log *Log) error {
+	var errs []error
+	for _, route := range self.Routes {
+		if route.Matches(log.Level) == false {
+			continue
+		}
+
+		if err := route.Appender.Append(log); err != nil {
+			errs = append(errs, fmt.Errorf("Error appending. Appender: %T Error: %v", route.Appender, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Sync every routed appender that implements `Syncer`. An appender
+// shared by several routes is synced once.
+func (self *LevelRoutingAppender) Sync() error {
+	return syncAppenders(self.appenders())
+}
+
+// Close every routed appender that implements `io.Closer`. An appender
+// shared by several routes is closed once.
+func (self *LevelRoutingAppender) Close() error {
+	return closeAppenders(self.appenders())
+}
+
+func (self *LevelRoutingAppender) appenders() []Appender {
+	appenders := make([]Appender, 0, len(self.Routes))
+	for _, route := range self.Routes {
+		if containsAppender(appenders, route.Appender) == false {
+			appenders = append(appenders, route.Appender)
+		}
+	}
+
+	return appenders
+}
+
+// Uncomparable appenders, e.g. funcs or structs holding a slice, are
+// never considered equal. Comparability is checked on the values
+// because a comparable type such as `FileAppender` can hold an
+// uncomparable writer.
+func containsAppender(appenders []Appender, appender Appender) bool {
+	if reflect.ValueOf(appender).Comparable() == false {
+		return false
+	}
+
+	for _, existing := range appenders {
+		if reflect.ValueOf(existing).Comparable() && existing == appender {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SamplingAppender forwards one of every N logs at each configured
+// level below WARN. WARN and above, and levels without a rate, are
+// never sampled.
+type SamplingAppender struct {
+	Appender Appender
+	rates    map[Level]uint64
+	counters map[Level]*uint64
+}
+
+func NewSamplingAppender(rates map[Level]uint64, appender Appender) *SamplingAppender {
+	ret := &SamplingAppender{
+		Appender: appender,
+		rates:    make(map[Level]uint64),
+		counters: make(map[Level]*uint64),
+	}
+
+	// The maps are only read after construction, so `Append` needs no
+	// lock beyond the atomic counter increment.
+	for level, rate := range rates {
+		if level < WARN && rate > 1 {
+			ret.rates[level] = rate
+			ret.counters[level] = new(uint64)
+		}
+	}
+
+	return ret
+}
+
+func (self *SamplingAppender) Append(log *Log) error {
+	if counter, sampled := self.counters[log.Level]; sampled {
+		if (atomic.AddUint64(counter, 1)-1)%self.rates[log.Level] != 0 {
+			return nil
+		}
+	}
+
+	return self.Appender.Append(log)
+}
+
+// Sync the wrapped appender if it implements `Syncer`.
+func (self *SamplingAppender) Sync() error {
+	return syncAppenders([]Appender{self.Appender})
+}
+
+// Close the wrapped appender if it implements `io.Closer`.
+func (self *SamplingAppender) Close() error {
+	return closeAppenders([]Appender{self.Appender})
+}
+
+// MemoryAppender keeps every formatted log in memory for tests to
+// assert on. It is safe for concurrent use and its zero value is ready
+// to use.
+type MemoryAppender struct {
+	sync.Mutex
+	logs     []string
+	appended chan struct{}
+}
+
+func (self *MemoryAppender) Append(log *Log) error {
+	self.Lock()
+	defer self.Unlock()
+
+	self.logs = append(self.logs, FormatLog(log))
+	if self.appended != nil {
+		close(self.appended)
+		self.appended = nil
+	}
+
+	return nil
+}
+
+// Return a copy of the formatted logs appended so far.
+func (self *MemoryAppender) Logs() []string {
+	self.Lock()
+	defer self.Unlock()
+
+	ret := make([]string, len(self.logs))
+	copy(ret, self.logs)
+	return ret
+}
+
+// Block until at least `count` logs have been appended or `timeout`
+// elapses, returning whether `count` was reached. Appending is
+// synchronous, so this is only needed when logging from other
+// goroutines.
+func (self *MemoryAppender) WaitForLogs(count int, timeout time.Duration) bool {
+	deadline := time.After(timeout)
+	for {
+		self.Lock()
+		if len(self.logs) >= count {
+			self.Unlock()
+			return true
+		}
+
+		if self.appended == nil {
+			self.appended = make(chan struct{})
+		}
+		appended := self.appended
+		self.Unlock()
+
+		select {
+		case <-appended:
+		case <-deadline:
+			return false
+		}
+	}
+}
+
 // Return true if the log should be passed to the underlying
 // `Appender`
 type Filter func(log *Log) bool
@@ -82,9 +747,22 @@ func (self *FilterAppender) Append(log *Log) error {
 	return self.Appender.Append(log)
 }
 
+// Sync the wrapped appender if it implements `Syncer`.
+func (self *FilterAppender) Sync() error {
+	return syncAppenders([]Appender{self.Appender})
+}
+
+// Close the wrapped appender if it implements `io.Closer`.
+func (self *FilterAppender) Close() error {
+	return closeAppenders([]Appender{self.Appender})
+}
+
+// Pass logs at or above `threshold`. A threshold of OFF passes
+// nothing and a threshold of ALL passes everything. Callers that used
+// OFF to pass everything, as it did before the sentinels, must use ALL.
 func LevelFilter(threshold Level, appender Appender) *FilterAppender {
 	filterFunc := func(log *Log) bool {
-		return log.Level >= threshold
+		return threshold != OFF && log.Level >= threshold
 	}
 
 	return &FilterAppender{
@@ -92,3 +770,119 @@ func LevelFilter(threshold Level, appender Appender) *FilterAppender {
 		Filter:   filterFunc,
 	}
 }
+
+// DedupeAppender collapses runs of consecutive logs that share a prefix
+// and message format. The first `Limit` logs of a run are forwarded to
+// `Appender` and the rest are counted. When the run ends, a summary log
+// "...repeated N times" is forwarded in their place. A run ends when a
+// different log arrives, when an identical log arrives more than
+// `Window` after the run started (a zero `Window` never expires), or on
+// `Flush`. A `Limit` below one is treated as one.
+type DedupeAppender struct {
+	Appender Appender
+	Window   time.Duration
+	Limit    int
+
+	sync.Mutex
+	first          *Log
+	count          int
+	lastSuppressed *Log
+}
+
+func NewDedupeAppender(window time.Duration, appender Appender) *DedupeAppender {
+	return &DedupeAppender{
+		Appender: appender,
+		Window:   window,
+		Limit:    1,
+	}
+}
+
+func (self *DedupeAppender) Append(log *Log) error {
+	self.Lock()
+	defer self.Unlock()
+
+	if self.continuesRun(log) {
+		self.count++
+		if self.count <= self.limit() {
+			return self.Appender.Append(log)
+		}
+
+		self.lastSuppressed = log
+		return nil
+	}
+
+	summaryErr := self.flush()
+	self.first = log
+	self.count = 1
+	if err := self.Appender.Append(log); err != nil {
+		return err
+	}
+
+	return summaryErr
+}
+
+// Forward the summary of the current run, if any logs were suppressed.
+// Call it before shutting down so a trailing run is not lost.
+func (self *DedupeAppender) Flush() error {
+	self.Lock()
+	defer self.Unlock()
+
+	return self.flush()
+}
+
+// Sync the wrapped appender if it implements `Syncer`. Logs held back
+// for the current run are not forwarded; see `Flush`.
+func (self *DedupeAppender) Sync() error {
+	return syncAppenders([]Appender{self.Appender})
+}
+
+// Flush, then close the wrapped appender if it implements `io.Closer`.
+func (self *DedupeAppender) Close() error {
+	flushErr := self.Flush()
+	if err := closeAppenders([]Appender{self.Appender}); err != nil {
+		return err
+	}
+
+	return flushErr
+}
+
+func (self *DedupeAppender) continuesRun(log *Log) bool {
+	if self.first == nil ||
+		self.first.Prefix != log.Prefix ||
+		self.first.messageFmt != log.messageFmt {
+		return false
+	}
+
+	return self.Window == 0 || log.Timestamp.Sub(self.first.Timestamp) <= self.Window
+}
+
+func (self *DedupeAppender) limit() int {
+	if self.Limit < 1 {
+		return 1
+	}
+
+	return self.Limit
+}
+
+func (self *DedupeAppender) flush() error {
+	suppressed := self.count - self.limit()
+	last := self.lastSuppressed
+	self.first = nil
+	self.count = 0
+	self.lastSuppressed = nil
+
+	if suppressed <= 0 {
+		return nil
+	}
+
+	return self.Appender.Append(&Log{
+		Prefix:     last.Prefix,
+		Level:      last.Level,
+		Filename:   last.Filename,
+		Line:       last.Line,
+		Timestamp:  last.Timestamp,
+		Fields:     last.Fields,
+		messageFmt: "...repeated %d times",
+		args:       []interface{}{suppressed},
+	})
+}
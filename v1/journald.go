@@ -0,0 +1,178 @@
+//go:build linux
+// +build linux
+
+package slogger
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+const journaldSocket = "/run/systemd/journal/socket"
+
+// JournaldAppender sends logs to the systemd journal using its native
+// protocol. Besides MESSAGE and PRIORITY, every log carries CODE_FILE,
+// CODE_LINE, SYSLOG_IDENTIFIER (the log's prefix), STACKTRACE (one frame
+// per line, when captured) and its `Fields` as additional journal
+// fields.
+type JournaldAppender struct {
+	conn   *net.UnixConn
+	socket *net.UnixAddr
+}
+
+func NewJournaldAppender() (*JournaldAppender, error) {
+	return newJournaldAppender(journaldSocket)
+}
+
+func newJournaldAppender(socket string) (*JournaldAppender, error) {
+	if _, err := os.Stat(socket); err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &JournaldAppender{conn, &net.UnixAddr{Name: socket, Net: "unixgram"}}, nil
+}
+
+func (self *JournaldAppender) Append(log *Log) error {
+	message := journaldMessage(log)
+	_, _, err := self.conn.WriteMsgUnix(message, nil, self.socket)
+	if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS) {
+		return self.appendViaFile(message)
+	}
+
+	return err
+}
+
+// Messages too large for a single datagram are written to an unlinked
+// temporary file whose descriptor is passed to journald instead.
+func (self *JournaldAppender) appendViaFile(message []byte) error {
+	file, err := ioutil.TempFile("/dev/shm", "slogger-journald-")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err = os.Remove(file.Name()); err != nil {
+		return err
+	}
+
+	if _, err = file.Write(message); err != nil {
+		return err
+	}
+
+	_, _, err = self.conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), self.socket)
+	return err
+}
+
+func (self *JournaldAppender) Close() error {
+	return self.conn.Close()
+}
+
+// Custom levels map to the priority of the nearest built-in level
+// below them.
+func journaldPriority(level Level) int {
+	switch {
+	case level >= ERROR:
+		return 3
+	case level >= WARN:
+		return 4
+	case level >= INFO:
+		return 6
+	}
+
+	return 7
+}
+
+// The fields written for every log. User fields normalizing to one of
+// these are prefixed with FIELD_ rather than adding a second value.
+var journaldReservedFields = map[string]bool{
+	"MESSAGE":           true,
+	"PRIORITY":          true,
+	"CODE_FILE":         true,
+	"CODE_LINE":         true,
+	"SYSLOG_IDENTIFIER": true,
+	"STACKTRACE":        true,
+}
+
+func journaldMessage(log *Log) []byte {
+	buffer := new(bytes.Buffer)
+	writeJournaldField(buffer, "MESSAGE", log.Message())
+	writeJournaldField(buffer, "PRIORITY", fmt.Sprintf("%d", journaldPriority(log.Level)))
+	writeJournaldField(buffer, "CODE_FILE", log.Filename)
+	writeJournaldField(buffer, "CODE_LINE", fmt.Sprintf("%d", log.Line))
+	if log.Prefix != "" {
+		writeJournaldField(buffer, "SYSLOG_IDENTIFIER", log.Prefix)
+	}
+
+	if len(log.Stacktrace) > 0 {
+		writeJournaldField(buffer, "STACKTRACE", strings.Join(log.Stacktrace, "\n"))
+	}
+
+	keys := make([]string, 0, len(log.Fields))
+	for key := range log.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := journaldFieldName(key)
+		if name == "" {
+			continue
+		}
+
+		if journaldReservedFields[name] {
+			name = "FIELD_" + name
+		}
+
+		writeJournaldField(buffer, name, fmt.Sprint(log.Fields[key]))
+	}
+
+	return buffer.Bytes()
+}
+
+// Values containing a newline use the binary form of the protocol: the
+// field name, a newline, the little-endian 64-bit length of the value
+// and the value itself.
+func writeJournaldField(buffer *bytes.Buffer, name, value string) {
+	buffer.WriteString(name)
+	if strings.Contains(value, "\n") {
+		buffer.WriteByte('\n')
+		binary.Write(buffer, binary.LittleEndian, uint64(len(value)))
+	} else {
+		buffer.WriteByte('=')
+	}
+
+	buffer.WriteString(value)
+	buffer.WriteByte('\n')
+}
+
+// Journal field names may only contain uppercase letters, digits and
+// underscores, may not start with an underscore or a digit and are at
+// most 64 characters long. Returns "" if nothing usable is left.
+func journaldFieldName(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for idx, char := range name {
+		if (char < 'A' || char > 'Z') && (char < '0' || char > '9') {
+			name[idx] = '_'
+		}
+	}
+
+	trimmed := strings.TrimLeft(string(name), "_0123456789")
+	if len(trimmed) > 64 {
+		trimmed = trimmed[:64]
+	}
+
+	return trimmed
+}
@@ -0,0 +1,101 @@
+//go:build linux
+// +build linux
+
+package slogger
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournaldAppender(test *testing.T) {
+	dir, err := ioutil.TempDir("", "slogger-journald")
+	if err != nil {
+		test.Fatalf("Cannot create a temporary directory. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		test.Fatalf("Cannot listen on `%v`. Err: %v", socket, err)
+	}
+	defer listener.Close()
+
+	appender, err := newJournaldAppender(socket)
+	if err != nil {
+		test.Fatalf("Cannot connect to `%v`. Err: %v", socket, err)
+	}
+	defer appender.Close()
+
+	log := &Log{
+		Prefix:     "agent.OplogTail",
+		Level:      WARN,
+		Filename:   "oplog.go",
+		Line:       88,
+		Fields:     Fields{"rs-id": "backup_test", "_internal": 1, "message": "shadowed", "code_line": 7},
+		messageFmt: "Tail restarted\non RsId: `%v`",
+		args:       []interface{}{"backup_test"},
+	}
+
+	if err = appender.Append(log); err != nil {
+		test.Fatalf("Error appending. Err: %v", err)
+	}
+
+	datagram := make([]byte, 4096)
+	num, err := listener.Read(datagram)
+	if err != nil {
+		test.Fatalf("Error reading the datagram. Err: %v", err)
+	}
+
+	const message = "Tail restarted\non RsId: `backup_test`"
+	expected := "MESSAGE\n" + string([]byte{byte(len(message)), 0, 0, 0, 0, 0, 0, 0}) + message + "\n" +
+		"PRIORITY=4\n" +
+		"CODE_FILE=oplog.go\n" +
+		"CODE_LINE=88\n" +
+		"SYSLOG_IDENTIFIER=agent.OplogTail\n" +
+		"INTERNAL=1\n" +
+		"FIELD_CODE_LINE=7\n" +
+		"FIELD_MESSAGE=shadowed\n" +
+		"RS_ID=backup_test\n"
+	if received := string(datagram[:num]); received != expected {
+		test.Errorf("Unexpected journal message. Received: %q", received)
+	}
+}
+
+func TestJournaldStacktrace(test *testing.T) {
+	message := string(journaldMessage(&Log{
+		Level:      ERROR,
+		Stacktrace: []string{"at agent/oplog.go:88", "at agent/agent.go:12"},
+		Fields:     Fields{"stacktrace": "shadowed"},
+		messageFmt: "Tail failed",
+	}))
+
+	const frames = "at agent/oplog.go:88\nat agent/agent.go:12"
+	expected := "STACKTRACE\n" + string([]byte{byte(len(frames)), 0, 0, 0, 0, 0, 0, 0}) + frames + "\n"
+	if strings.Contains(message, expected) == false {
+		test.Errorf("Expected a STACKTRACE field. Received: %q", message)
+	}
+
+	if strings.Contains(message, "FIELD_STACKTRACE=shadowed\n") == false {
+		test.Errorf("Expected the user field to be prefixed. Received: %q", message)
+	}
+}
+
+func TestJournaldFieldName(test *testing.T) {
+	for key, expected := range map[string]string{
+		"requestId":             "REQUESTID",
+		"user.name":             "USER_NAME",
+		"__1st":                 "ST",
+		"9":                     "",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	} {
+		if received := journaldFieldName(key); received != expected {
+			test.Errorf("journaldFieldName(%q); Expected: %q Received: %q", key, expected, received)
+		}
+	}
+}
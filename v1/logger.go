@@ -5,15 +5,22 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// Structured key/value context attached to a log.
+type Fields map[string]interface{}
+
 type Log struct {
 	Prefix     string
 	Level      Level
 	Filename   string
 	Line       int
 	Timestamp  time.Time
+	Fields     Fields
+	Stacktrace []string
 	messageFmt string
 	args       []interface{}
 }
@@ -25,6 +32,32 @@ func (self *Log) Message() string {
 type Logger struct {
 	Prefix    string
 	Appenders []Appender
+	// Every log shares this map rather than a copy, including logs held
+	// by the `Cache` and by appenders. Treat it as read-only once the
+	// logger is in use and add fields with `With`, which builds a new
+	// map.
+	Fields Fields
+	// Logs at or above this level carry the stacktrace of the logging
+	// call. Capturing is not free, so the zero value, OFF, disables it.
+	StacktraceLevel Level
+}
+
+// Return a child logger that shares the prefix and appenders of
+// `self`. Logs from the child carry the parent's fields merged with
+// `key`/`value`. The parent logger is not modified.
+func (self *Logger) With(key string, value interface{}) *Logger {
+	fields := make(Fields, len(self.Fields)+1)
+	for k, v := range self.Fields {
+		fields[k] = v
+	}
+	fields[key] = value
+
+	return &Logger{
+		Prefix:          self.Prefix,
+		Appenders:       self.Appenders,
+		Fields:          fields,
+		StacktraceLevel: self.StacktraceLevel,
+	}
 }
 
 // Log a message and a level to a logger instance. This returns a
@@ -56,6 +89,40 @@ func (self *Logger) Stackf(level Level, stackErr error, messageFmt string, args
 	return self.logf(level, messageFmt, args...)
 }
 
+// Log `msg` with `err` attached under the "error" field and return
+// `err` unchanged. This collapses the log-and-return pattern:
+//
+// if err := tail.Start(); err != nil {
+//     return logger.LogErr(slogger.WARN, err, "Failed to start the tail.")
+// }
+//
+// A nil `err` logs `msg` without the field and returns nil.
+func (self *Logger) LogErr(level Level, err error, msg string) error {
+	logger := self
+	if err != nil {
+		logger = self.With("error", err)
+	}
+
+	logger.logf(level, strings.Replace(msg, "%", "%%", -1))
+	return err
+}
+
+func (self *Logger) Debug(messageFmt string, args ...interface{}) (*Log, []error) {
+	return self.logf(DEBUG, messageFmt, args...)
+}
+
+func (self *Logger) Info(messageFmt string, args ...interface{}) (*Log, []error) {
+	return self.logf(INFO, messageFmt, args...)
+}
+
+func (self *Logger) Warn(messageFmt string, args ...interface{}) (*Log, []error) {
+	return self.logf(WARN, messageFmt, args...)
+}
+
+func (self *Logger) Error(messageFmt string, args ...interface{}) (*Log, []error) {
+	return self.logf(ERROR, messageFmt, args...)
+}
+
 func (self *Logger) logf(level Level, messageFmt string, args ...interface{}) (*Log, []error) {
 	var errors []error
 
@@ -72,10 +139,15 @@ func (self *Logger) logf(level Level, messageFmt string, args ...interface{}) (*
 		Filename:   file,
 		Line:       line,
 		Timestamp:  time.Now(),
+		Fields:     self.Fields,
 		messageFmt: messageFmt,
 		args:       args,
 	}
 
+	if self.StacktraceLevel != OFF && level >= self.StacktraceLevel {
+		log.Stacktrace = stacktrace(1)
+	}
+
 	Cache.Add(log)
 
 	for _, appender := range self.Appenders {
@@ -91,33 +163,105 @@ func (self *Logger) logf(level Level, messageFmt string, args ...interface{}) (*
 type Level uint8
 
 // The level is in an order such that the expressions
-// `level < WARN`, `level >= INFO` have intuitive meaning.
+// `level < WARN`, `level >= INFO` have intuitive meaning. The built-in
+// levels are spaced apart so that custom levels registered with
+// `RegisterLevel` can be ordered between them.
+//
+// Compatibility: before custom levels, DEBUG through ERROR were 1
+// through 4. Code that stores or configures levels as raw numbers must
+// be updated, e.g. `Level(3)` used to be WARN and is now unregistered.
+// Levels referenced by name are unaffected.
+//
+// OFF and ALL are sentinels for filtering and are never the level of
+// an actual log: a threshold of OFF filters everything and a threshold
+// of ALL filters nothing. This changed with the sentinels: OFF used to
+// be the lowest level, so `LevelFilter(OFF, ...)` passed every log,
+// and `OFF.Type()` was "off?" rather than "off".
 const (
-	OFF Level = iota
-	DEBUG
-	INFO
-	WARN
-	ERROR
+	OFF   Level = 0
+	ALL   Level = 1
+	DEBUG Level = 10
+	INFO  Level = 20
+	WARN  Level = 30
+	ERROR Level = 40
+)
+
+// Level names are read on every formatted log but only written by
+// `RegisterLevel`, so registration replaces a copy of the map instead
+// of making readers take a lock.
+var (
+	levelNames    atomic.Value // map[Level]string
+	levelRegister sync.Mutex
 )
 
+func init() {
+	levelNames.Store(map[Level]string{
+		OFF:   "off",
+		ALL:   "all",
+		DEBUG: "debug",
+		INFO:  "info",
+		WARN:  "warn",
+		ERROR: "error",
+	})
+}
+
+// Register a custom level with a name and a severity. The severity
+// orders the level relative to the built-in levels, e.g. a NOTICE
+// level between INFO and WARN:
+//
+// var NOTICE = slogger.Level(25)
+// err := slogger.RegisterLevel("notice", NOTICE)
+func RegisterLevel(name string, level Level) error {
+	levelRegister.Lock()
+	defer levelRegister.Unlock()
+
+	names := levelNames.Load().(map[Level]string)
+	if existing, exists := names[level]; exists {
+		return fmt.Errorf("Level severity already registered. Severity: %d Name: %v", level, existing)
+	}
+
+	updated := make(map[Level]string, len(names)+1)
+	for existingLevel, existing := range names {
+		if existing == name {
+			return fmt.Errorf("Level name already registered. Name: %v", name)
+		}
+
+		updated[existingLevel] = existing
+	}
+
+	updated[level] = name
+	levelNames.Store(updated)
+	return nil
+}
+
+// Return the level registered under `name`.
+func ParseLevel(name string) (Level, error) {
+	for level, existing := range levelNames.Load().(map[Level]string) {
+		if existing == name {
+			return level, nil
+		}
+	}
+
+	return OFF, fmt.Errorf("Unknown level. Name: %v", name)
+}
+
 func (self Level) Type() string {
-	switch self {
-	case ERROR:
-		return "error"
-	case WARN:
-		return "warn"
-	case INFO:
-		return "info"
-	case DEBUG:
-		return "debug"
+	if name, exists := levelNames.Load().(map[Level]string)[self]; exists {
+		return name
 	}
 
 	return "off?"
 }
 
-func stacktrace() []string {
+func (self Level) String() string {
+	return self.Type()
+}
+
+// Return the stacktrace of the function calling `stacktrace`'s caller,
+// omitting a further `skip` frames.
+func stacktrace(skip int) []string {
 	ret := make([]string, 0, 2)
-	for skip := 2; true; skip++ {
+	for skip += 2; true; skip++ {
 		_, file, line, ok := runtime.Caller(skip)
 		if ok == false {
 			break
@@ -137,7 +281,7 @@ type StackError struct {
 func NewStackError(messageFmt string, args ...interface{}) *StackError {
 	return &StackError{
 		Message:    fmt.Sprintf(messageFmt, args...),
-		Stacktrace: stacktrace(),
+		Stacktrace: stacktrace(0),
 	}
 }
 
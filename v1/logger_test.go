@@ -2,12 +2,16 @@ package slogger
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestLevels(test *testing.T) {
@@ -33,6 +37,159 @@ func TestFormat(test *testing.T) {
 	}
 }
 
+func TestTimestampLayout(test *testing.T) {
+	TimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+	defer func() { TimestampLayout = DefaultTimestampLayout }()
+
+	log := Log{
+		Prefix:     "agent.OplogTail",
+		Level:      INFO,
+		Filename:   "oplog.go",
+		Line:       88,
+		Timestamp:  time.Date(2013, time.March, 7, 14, 5, 9, 123000000, time.UTC),
+		messageFmt: "Tail started",
+	}
+
+	expected := "[2013-03-07T14:05:09.123Z] [agent.OplogTail.info] [oplog.go:88] Tail started\n"
+	if received := FormatLog(&log); received != expected {
+		test.Errorf("Improperly formatted log. Received: `%v`", received)
+	}
+}
+
+func TestTimestampTransform(test *testing.T) {
+	TimestampTransform = func(timestamp time.Time) time.Time {
+		return timestamp.Round(time.Second)
+	}
+	defer func() { TimestampTransform = nil }()
+
+	log := Log{
+		Prefix:     "agent.OplogTail",
+		Level:      INFO,
+		Filename:   "oplog.go",
+		Line:       88,
+		Timestamp:  time.Date(2013, time.March, 7, 14, 5, 9, 623000000, time.UTC),
+		messageFmt: "Tail started",
+	}
+
+	expected := "[2013/03/07 14:05:10] [agent.OplogTail.info] [oplog.go:88] Tail started\n"
+	if received := FormatLog(&log); received != expected {
+		test.Errorf("Expected the transformed timestamp. Received: `%v`", received)
+	}
+
+	if log.Timestamp.Nanosecond() != 623000000 {
+		test.Errorf("The transform should not modify the log. Timestamp: %v", log.Timestamp)
+	}
+}
+
+func TestElapsedSince(test *testing.T) {
+	start := time.Date(2013, time.March, 7, 14, 5, 9, 0, time.UTC)
+	ElapsedSince = start
+	defer func() { ElapsedSince = time.Time{} }()
+
+	log := Log{
+		Prefix:     "agent.OplogTail",
+		Level:      INFO,
+		Filename:   "oplog.go",
+		Line:       88,
+		Timestamp:  start.Add(time.Minute + 23456*time.Millisecond),
+		messageFmt: "Tail started",
+	}
+
+	expected := "[2013/03/07 14:06:32] [+00:01:23.456] [agent.OplogTail.info] [oplog.go:88] Tail started\n"
+	if received := FormatLog(&log); received != expected {
+		test.Errorf("Expected the elapsed time after the timestamp. Received: `%v`", received)
+	}
+
+	if received := FormatElapsed(-(26*time.Hour + 5*time.Millisecond)); received != "-26:00:00.005" {
+		test.Errorf("Bad elapsed format. Received: `%v`", received)
+	}
+}
+
+func TestLevelLabels(test *testing.T) {
+	LevelLabels = ShortLevelLabels
+	defer func() { LevelLabels = nil }()
+
+	for level, label := range map[Level]string{DEBUG: "D", INFO: "I", WARN: "W", ERROR: "E"} {
+		log := Log{
+			Prefix:     "agent.OplogTail",
+			Level:      level,
+			Filename:   "oplog.go",
+			Line:       88,
+			messageFmt: "Tail started",
+		}
+
+		received := FormatLog(&log)
+		if strings.HasPrefix(received, "["+label+"] ") == false {
+			test.Errorf("Expected the line to start with the `%v` label for %v. Received: `%v`",
+				label, level.Type(), received)
+		}
+
+		expected := "[" + label + "] [0001/01/01 00:00:00] [agent.OplogTail] [oplog.go:88] Tail started\n"
+		if received != expected {
+			test.Errorf("Improperly formatted log. Received: `%v`", received)
+		}
+	}
+
+	unlabeled := Log{Prefix: "agent.OplogTail", Level: Level(99), Filename: "oplog.go", Line: 88}
+	expected := "[0001/01/01 00:00:00] [agent.OplogTail.off?] [oplog.go:88] \n"
+	if received := FormatLog(&unlabeled); received != expected {
+		test.Errorf("Expected unlabeled levels to keep the default layout. Received: `%v`", received)
+	}
+
+	if FormatLevel(Level(99)) != "off?" {
+		test.Errorf("Expected unlabeled levels to render their name. Received: `%v`", FormatLevel(Level(99)))
+	}
+}
+
+func TestSanitizeUTF8(test *testing.T) {
+	log := Log{
+		Prefix:     "agent.OplogTail",
+		Level:      INFO,
+		Filename:   "oplog.go",
+		Line:       88,
+		Fields:     Fields{"host": "db\xff1"},
+		messageFmt: "Tail started on `%v`",
+		args:       []interface{}{"caf\xe9"},
+	}
+
+	expected := "[0001/01/01 00:00:00] [agent.OplogTail.info] [oplog.go:88] Tail started on `caf\uFFFD` host=db\uFFFD1\n"
+	if received := FormatLog(&log); received != expected {
+		test.Errorf("Expected invalid UTF-8 to be replaced. Received: %q", received)
+	}
+
+	SanitizeUTF8 = false
+	defer func() { SanitizeUTF8 = true }()
+
+	expected = "[0001/01/01 00:00:00] [agent.OplogTail.info] [oplog.go:88] Tail started on `caf\xe9` host=db\xff1\n"
+	if received := FormatLog(&log); received != expected {
+		test.Errorf("Expected the bytes to pass through. Received: %q", received)
+	}
+}
+
+func TestFormatFields(test *testing.T) {
+	log := Log{
+		Prefix:     "agent.OplogTail",
+		Level:      INFO,
+		Filename:   "oplog.go",
+		Line:       88,
+		Fields:     Fields{"rsId": "backup_test", "attempt": 2, "reason": "not master"},
+		messageFmt: "Tail started",
+	}
+
+	expected := "[0001/01/01 00:00:00] [agent.OplogTail.info] [oplog.go:88] Tail started " +
+		"attempt=2 reason=\"not master\" rsId=backup_test\n"
+	received := FormatLog(&log)
+	if received != expected {
+		test.Errorf("Improperly formatted log. Received: `%v`", received)
+	}
+
+	fields := Fields{"a b": "c", "k=": "v", "e": "", "": "blank", "q\"": "x"}
+	expected = `""=blank "a b"=c e="" "k="=v "q\""=x`
+	if received = FormatFields(fields); received != expected {
+		test.Errorf("Expected keys and empty values to be quoted. Received: `%v`", received)
+	}
+}
+
 func TestLog(test *testing.T) {
 	const logFilename = "logger_test.output"
 	logfile, err := os.Create(logFilename)
@@ -63,6 +220,90 @@ func TestLog(test *testing.T) {
 	}
 }
 
+type writerOnly struct {
+	buffer bytes.Buffer
+}
+
+func (self *writerOnly) Write(bytes []byte) (int, error) {
+	return self.buffer.Write(bytes)
+}
+
+func TestWriterAppender(test *testing.T) {
+	writer := &writerOnly{}
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{NewWriterAppender(writer)},
+	}
+
+	logger.Logf(INFO, "Tail started")
+	if strings.HasSuffix(writer.buffer.String(), "] Tail started\n") == false {
+		test.Errorf("Expected the log to be written. Received: `%v`", writer.buffer.String())
+	}
+}
+
+type closingWriter struct {
+	writerOnly
+	closed bool
+}
+
+func (self *closingWriter) Close() error {
+	self.closed = true
+	return nil
+}
+
+func TestWriterAppenderClose(test *testing.T) {
+	writer := &closingWriter{}
+	multi := NewMultiAppender(LevelFilter(WARN, NewWriterAppender(writer)), StdErrAppender())
+	if err := multi.Close(); err != nil || writer.closed == false {
+		test.Errorf("Expected Close to close the writer. Closed: %v Err: %v", writer.closed, err)
+	}
+
+	if _, err := os.Stderr.Stat(); err != nil {
+		test.Errorf("Expected stderr to stay open. Err: %v", err)
+	}
+}
+
+type syncingWriter struct {
+	writerOnly
+	syncs int
+	err   error
+}
+
+func (self *syncingWriter) WriteString(str string) (int, error) {
+	return self.Write([]byte(str))
+}
+
+func (self *syncingWriter) Sync() error {
+	self.syncs++
+	return self.err
+}
+
+func TestMultiAppenderSync(test *testing.T) {
+	filtered := &syncingWriter{}
+	direct := &syncingWriter{}
+	failing := &syncingWriter{err: errors.New("disk full")}
+	routed := &syncingWriter{}
+	routing := NewLevelRoutingAppender(
+		LevelRoute{Min: DEBUG, Max: INFO, Appender: NewWriterAppender(routed)},
+		LevelRoute{Min: WARN, Appender: FileAppender{failing}})
+	multi := NewMultiAppender(
+		LevelFilter(WARN, NewWriterAppender(filtered)),
+		FileAppender{direct},
+		routing,
+		StdErrAppender(),
+		&countingAppender{})
+
+	err := multi.Sync()
+	if err == nil || strings.Contains(err.Error(), "disk full") == false {
+		test.Errorf("Expected the failing writer's error. Received: %v", err)
+	}
+
+	if filtered.syncs != 1 || direct.syncs != 1 || failing.syncs != 1 || routed.syncs != 1 {
+		test.Errorf("Expected every writer to be synced once. Filtered: %d Direct: %d Failing: %d Routed: %d",
+			filtered.syncs, direct.syncs, failing.syncs, routed.syncs)
+	}
+}
+
 func TestCopy(test *testing.T) {
 	CapLogCache(10)
 
@@ -259,3 +500,519 @@ func TestStacktracing(test *testing.T) {
 		test.Errorf("Expected a log message when adding -4.")
 	}
 }
+
+func TestWith(test *testing.T) {
+	counter := &countingAppender{}
+	parent := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{counter},
+	}
+
+	child := parent.With("rsId", "backup_test").With("attempt", 2)
+	if len(parent.Fields) != 0 {
+		test.Errorf("With should not modify the parent. Parent fields: %v", parent.Fields)
+	}
+
+	log, _ := child.Info("Tail started")
+	if log.Fields["rsId"] != "backup_test" || log.Fields["attempt"] != 2 {
+		test.Errorf("Expected the log to carry the merged fields. Received: %v", log.Fields)
+	}
+
+	if log.Level != INFO || strings.Contains(log.Filename, "logger_test.go") == false {
+		test.Errorf("Unexpected level or caller. Level: %v Filename: %v", log.Level.Type(), log.Filename)
+	}
+
+	if counter.count != 1 {
+		test.Errorf("Expected the child to append through the parent's appenders. Received: %d",
+			counter.count)
+	}
+}
+
+func TestFormatHelpers(test *testing.T) {
+	if FormatLevel(WARN) != "warn" {
+		test.Errorf("Bad level format. Received: `%v`", FormatLevel(WARN))
+	}
+
+	if FormatCaller("oplog.go", 88) != "oplog.go:88" {
+		test.Errorf("Bad caller format. Received: `%v`", FormatCaller("oplog.go", 88))
+	}
+
+	timestamp := time.Date(2013, time.March, 7, 14, 5, 9, 0, time.UTC)
+	if received := FormatTimestamp(timestamp, DefaultTimestampLayout); received != "2013/03/07 14:05:09" {
+		test.Errorf("Bad timestamp format. Received: `%v`", received)
+	}
+}
+
+// Remove a level registered by a test so that the test can run again.
+func unregisterLevel(level Level) {
+	levelRegister.Lock()
+	defer levelRegister.Unlock()
+
+	names := levelNames.Load().(map[Level]string)
+	updated := make(map[Level]string, len(names))
+	for existingLevel, existing := range names {
+		if existingLevel != level {
+			updated[existingLevel] = existing
+		}
+	}
+
+	levelNames.Store(updated)
+}
+
+func TestRegisterLevel(test *testing.T) {
+	const NOTICE = Level(25)
+	if err := RegisterLevel("notice", NOTICE); err != nil {
+		test.Fatalf("Failed to register a level. Err: %v", err)
+	}
+	defer unregisterLevel(NOTICE)
+
+	if NOTICE.Type() != "notice" {
+		test.Errorf("Expected the registered name. Received: `%v`", NOTICE.Type())
+	}
+
+	if level, err := ParseLevel("notice"); err != nil || level != NOTICE {
+		test.Errorf("Failed to parse a registered level. Level: %d Err: %v", level, err)
+	}
+
+	if level, err := ParseLevel("warn"); err != nil || level != WARN {
+		test.Errorf("Failed to parse a built-in level. Level: %d Err: %v", level, err)
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		test.Errorf("Expected an error parsing an unknown level.")
+	}
+
+	if err := RegisterLevel("notice", Level(26)); err == nil {
+		test.Errorf("Expected an error registering a duplicate name.")
+	}
+
+	if err := RegisterLevel("warning", WARN); err == nil {
+		test.Errorf("Expected an error registering a duplicate severity.")
+	}
+
+	counter := &countingAppender{}
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{LevelFilter(NOTICE, counter)},
+	}
+
+	logger.Logf(INFO, "Filtered")
+	logger.Logf(NOTICE, "Passed")
+	logger.Logf(WARN, "Passed")
+	if counter.count != 2 {
+		test.Errorf("Expected the custom level to order between INFO and WARN. Received: %d",
+			counter.count)
+	}
+}
+
+func TestLogErr(test *testing.T) {
+	CapLogCache(10)
+
+	logBuffer := new(bytes.Buffer)
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{NewStringAppender(logBuffer)},
+	}
+
+	testErr := errors.New("connection refused")
+	if err := logger.LogErr(WARN, testErr, "Failed to reach 100% of members."); err != testErr {
+		test.Errorf("Expected the same error to be returned. Received: %v", err)
+	}
+
+	cache := Cache.Copy()
+	log := cache[len(cache)-1]
+	if log.Fields["error"] != testErr {
+		test.Errorf("Expected the error in the log fields. Received: %v", log.Fields)
+	}
+
+	if log.Message() != "Failed to reach 100% of members." {
+		test.Errorf("Unexpected message. Received: `%v`", log.Message())
+	}
+
+	if len(logger.Fields) != 0 {
+		test.Errorf("LogErr should not modify the logger. Fields: %v", logger.Fields)
+	}
+
+	if err := logger.LogErr(INFO, nil, "Nothing went wrong."); err != nil {
+		test.Errorf("Expected a nil error to be returned. Received: %v", err)
+	}
+
+	cache = Cache.Copy()
+	if log = cache[len(cache)-1]; log.Message() != "Nothing went wrong." || len(log.Fields) != 0 {
+		test.Errorf("Expected the message without fields. Message: `%v` Fields: %v",
+			log.Message(), log.Fields)
+	}
+}
+
+func TestFilterSentinels(test *testing.T) {
+	CapLogCache(10)
+
+	offCounter := &countingAppender{}
+	allCounter := &countingAppender{}
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{LevelFilter(OFF, offCounter), LevelFilter(ALL, allCounter)},
+	}
+
+	logger.Logf(DEBUG, "%d", 0)
+	logger.Logf(INFO, "%d", 1)
+	logger.Logf(ERROR, "%d", 2)
+
+	if offCounter.count != 0 {
+		test.Errorf("Expected an OFF threshold to filter everything. Received: %d", offCounter.count)
+	}
+
+	if allCounter.count != 3 {
+		test.Errorf("Expected an ALL threshold to filter nothing. Received: %d", allCounter.count)
+	}
+
+	if level, err := ParseLevel("off"); err != nil || level != OFF {
+		test.Errorf("Failed to parse `off`. Level: %d Err: %v", level, err)
+	}
+
+	if level, err := ParseLevel("all"); err != nil || level != ALL {
+		test.Errorf("Failed to parse `all`. Level: %d Err: %v", level, err)
+	}
+}
+
+type failingAppender struct {
+	closed bool
+}
+
+func (self *failingAppender) Append(log *Log) error {
+	return errors.New("disk full")
+}
+
+func (self *failingAppender) Close() error {
+	self.closed = true
+	return nil
+}
+
+func TestMultiAppender(test *testing.T) {
+	CapLogCache(10)
+
+	failing := &failingAppender{}
+	counter := &countingAppender{}
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{NewMultiAppender(failing, counter, counter)},
+	}
+
+	_, errs := logger.Logf(INFO, "Tail started")
+	if counter.count != 2 {
+		test.Errorf("Expected the log to reach every appender. Received: %d", counter.count)
+	}
+
+	if len(errs) != 1 || strings.Contains(errs[0].Error(), "disk full") == false {
+		test.Errorf("Expected the failing appender's error. Received: %v", errs)
+	}
+
+	multi := logger.Appenders[0].(*MultiAppender)
+	if err := multi.Close(); err != nil || failing.closed == false {
+		test.Errorf("Expected Close to close the closable appender. Closed: %v Err: %v",
+			failing.closed, err)
+	}
+}
+
+func TestFilterAppenderClose(test *testing.T) {
+	failing := &failingAppender{}
+	multi := NewMultiAppender(LevelFilter(WARN, failing))
+	if err := multi.Close(); err != nil || failing.closed == false {
+		test.Errorf("Expected Close to reach the filtered appender. Closed: %v Err: %v",
+			failing.closed, err)
+	}
+}
+
+type recordingAppender struct {
+	messages []string
+}
+
+func (self *recordingAppender) Append(log *Log) error {
+	self.messages = append(self.messages, log.Message())
+	return nil
+}
+
+func TestDedupeAppender(test *testing.T) {
+	recorder := &recordingAppender{}
+	dedupe := NewDedupeAppender(time.Minute, recorder)
+
+	start := time.Date(2013, time.March, 7, 14, 5, 9, 0, time.UTC)
+	appendAt := func(offset time.Duration, messageFmt string, args ...interface{}) {
+		dedupe.Append(&Log{
+			Prefix:     "agent.OplogTail",
+			Level:      ERROR,
+			Timestamp:  start.Add(offset),
+			messageFmt: messageFmt,
+			args:       args,
+		})
+	}
+
+	for idx := 0; idx < 5; idx++ {
+		appendAt(time.Duration(idx)*time.Second, "Lost connection to %v", "host1")
+	}
+	appendAt(6*time.Second, "Reconnected")
+	appendAt(7*time.Second, "Lost connection to %v", "host2")
+	appendAt(2*time.Minute, "Lost connection to %v", "host2")
+	appendAt(2*time.Minute+time.Second, "Lost connection to %v", "host2")
+	dedupe.Flush()
+
+	expected := []string{
+		"Lost connection to host1",
+		"...repeated 4 times",
+		"Reconnected",
+		"Lost connection to host2",
+		"Lost connection to host2",
+		"...repeated 1 times",
+	}
+
+	if fmt.Sprint(recorder.messages) != fmt.Sprint(expected) {
+		test.Errorf("Unexpected messages.\nExpected: %q\nReceived: %q", expected, recorder.messages)
+	}
+}
+
+func TestMemoryAppender(test *testing.T) {
+	memory := &MemoryAppender{}
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{memory},
+	}
+
+	go func() {
+		for idx := 0; idx < 3; idx++ {
+			logger.Logf(INFO, "%d", idx)
+		}
+	}()
+
+	if memory.WaitForLogs(3, 5*time.Second) == false {
+		test.Fatalf("Timed out waiting for logs. Received: %v", memory.Logs())
+	}
+
+	for idx, log := range memory.Logs() {
+		if strings.HasSuffix(log, fmt.Sprintf("] %d\n", idx)) == false {
+			test.Errorf("Unexpected log. Idx: %d Received: `%v`", idx, log)
+		}
+	}
+
+	if memory.WaitForLogs(4, 10*time.Millisecond) {
+		test.Errorf("Did not expect a fourth log.")
+	}
+}
+
+func TestLevelRoutingAppender(test *testing.T) {
+	CapLogCache(10)
+
+	appLog := &countingAppender{}
+	errorLog := &failingAppender{}
+	everything := &countingAppender{}
+	nothing := &countingAppender{}
+	routing := NewLevelRoutingAppender(
+		LevelRoute{Min: DEBUG, Max: INFO, Appender: appLog},
+		LevelRoute{Min: WARN, Appender: errorLog},
+		LevelRoute{Min: ALL, Max: ALL, Appender: everything},
+		LevelRoute{Min: OFF, Appender: nothing},
+		LevelRoute{Max: ERROR, Appender: nothing},
+		LevelRoute{Min: ERROR, Appender: errorLog})
+
+	logger := &Logger{
+		Prefix:    "agent.OplogTail",
+		Appenders: []Appender{routing},
+	}
+
+	logger.Logf(DEBUG, "%d", 0)
+	logger.Logf(INFO, "%d", 1)
+	if _, errs := logger.Logf(WARN, "%d", 2); len(errs) != 1 {
+		test.Errorf("Expected one error from the WARN route. Received: %v", errs)
+	}
+
+	if _, errs := logger.Logf(ERROR, "%d", 3); len(errs) != 1 {
+		test.Errorf("Expected one aggregated error from both ERROR routes. Received: %v", errs)
+	}
+
+	if appLog.count != 2 {
+		test.Errorf("Expected DEBUG and INFO in the app log. Received: %d", appLog.count)
+	}
+
+	if everything.count != 4 {
+		test.Errorf("Expected every log in the unbounded route. Received: %d", everything.count)
+	}
+
+	if nothing.count != 0 {
+		test.Errorf("Expected a `Min` of OFF to match nothing. Received: %d", nothing.count)
+	}
+
+	if err := routing.Close(); err != nil || errorLog.closed == false {
+		test.Errorf("Expected Close to close the error log. Closed: %v Err: %v", errorLog.closed, err)
+	}
+}
+
+type sliceWriter struct {
+	lines []string
+}
+
+func (self sliceWriter) WriteString(str string) (int, error) {
+	return len(str), nil
+}
+
+func TestLevelRoutingAppenderUncomparable(test *testing.T) {
+	shared := FileAppender{sliceWriter{}}
+	routing := NewLevelRoutingAppender(
+		LevelRoute{Min: DEBUG, Max: INFO, Appender: shared},
+		LevelRoute{Min: WARN, Appender: shared})
+
+	if err := routing.Close(); err != nil {
+		test.Errorf("Unexpected error closing. Err: %v", err)
+	}
+}
+
+type atomicCountingAppender struct {
+	count int64
+}
+
+func (self *atomicCountingAppender) Append(log *Log) error {
+	atomic.AddInt64(&self.count, 1)
+	return nil
+}
+
+func TestSamplingAppender(test *testing.T) {
+	debugs := &atomicCountingAppender{}
+	warns := &atomicCountingAppender{}
+	rates := map[Level]uint64{DEBUG: 10, WARN: 10}
+	debugSampler := NewSamplingAppender(rates, debugs)
+	warnSampler := NewSamplingAppender(rates, warns)
+
+	var wait sync.WaitGroup
+	for goroutine := 0; goroutine < 10; goroutine++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for idx := 0; idx < 100; idx++ {
+				debugSampler.Append(&Log{Level: DEBUG})
+				warnSampler.Append(&Log{Level: WARN})
+			}
+		}()
+	}
+	wait.Wait()
+
+	if debugs.count != 100 {
+		test.Errorf("Expected one in ten DEBUG logs. Received: %d", debugs.count)
+	}
+
+	if warns.count != 1000 {
+		test.Errorf("Expected WARN logs to never be sampled. Received: %d", warns.count)
+	}
+}
+
+func TestSamplingAppenderKeepsErrors(test *testing.T) {
+	for _, rate := range []uint64{0, 1, 2, 10, 100, 1 << 63} {
+		errorLogs := &atomicCountingAppender{}
+		sampler := NewSamplingAppender(map[Level]uint64{DEBUG: rate, INFO: rate, WARN: rate, ERROR: rate}, errorLogs)
+		for idx := 0; idx < 1000; idx++ {
+			sampler.Append(&Log{Level: ERROR})
+		}
+
+		if errorLogs.count != 1000 {
+			test.Errorf("Expected no ERROR log to be dropped. Rate: %d Received: %d", rate, errorLogs.count)
+		}
+	}
+}
+
+func TestPutBuffer(test *testing.T) {
+	oversized := bytes.NewBuffer(make([]byte, 0, 2*maxPooledBuffer))
+	putBuffer(oversized)
+	if bufferPool.Get().(*bytes.Buffer) == oversized {
+		test.Errorf("Expected an oversized buffer to be dropped.")
+	}
+}
+
+func BenchmarkFileAppender(bench *testing.B) {
+	appender := NewWriterAppender(ioutil.Discard)
+	log := &Log{
+		Prefix:     "agent.OplogTail",
+		Level:      INFO,
+		Filename:   "oplog.go",
+		Line:       88,
+		Fields:     Fields{"rsId": "backup_test"},
+		messageFmt: "Tail started. Attempt: %d",
+		args:       []interface{}{1},
+	}
+
+	bench.ReportAllocs()
+	for idx := 0; idx < bench.N; idx++ {
+		appender.Append(log)
+	}
+}
+
+func TestLogStacktrace(test *testing.T) {
+	CapLogCache(10)
+
+	memory := &MemoryAppender{}
+	logger := &Logger{
+		Prefix:          "agent.OplogTail",
+		Appenders:       []Appender{memory},
+		StacktraceLevel: ERROR,
+	}
+
+	if log, _ := logger.Logf(WARN, "Below the stacktrace level"); log.Stacktrace != nil {
+		test.Errorf("Did not expect a stacktrace below ERROR. Received: %v", log.Stacktrace)
+	}
+
+	log, _ := logger.Error("Tail failed")
+	if len(log.Stacktrace) == 0 || strings.Contains(log.Stacktrace[0], "logger_test.go:") == false {
+		test.Fatalf("Expected the stacktrace to start at the caller. Received: %v", log.Stacktrace)
+	}
+
+	formatted := memory.Logs()[1]
+	expected := "] Tail failed\n\t" + log.Stacktrace[0] + "\n"
+	if strings.Contains(formatted, expected) == false {
+		test.Errorf("Expected the stacktrace on the following lines. Received:\n%v", formatted)
+	}
+
+	if log, _ = logger.With("rsId", "backup_test").Error("Tail failed"); len(log.Stacktrace) == 0 {
+		test.Errorf("Expected a child logger to keep the stacktrace level.")
+	}
+
+	logger.LogErr(ERROR, errors.New("connection refused"), "Tail failed")
+	cache := Cache.Copy()
+	if log = cache[len(cache)-1]; len(log.Stacktrace) == 0 ||
+		strings.Contains(log.Stacktrace[0], "logger_test.go:") == false {
+		test.Errorf("Expected LogErr to capture the stacktrace of its caller. Received: %v", log.Stacktrace)
+	}
+
+	logger.StacktraceLevel = OFF
+	if log, _ = logger.Error("Capture disabled"); log.Stacktrace != nil {
+		test.Errorf("Did not expect a stacktrace when disabled. Received: %v", log.Stacktrace)
+	}
+}
+
+func TestConsoleAppender(test *testing.T) {
+	log := &Log{
+		Prefix:     "agent.OplogTail",
+		Level:      WARN,
+		Filename:   "oplog.go",
+		Line:       88,
+		messageFmt: "Tail lagging",
+	}
+
+	colored := new(bytes.Buffer)
+	NewConsoleAppender(colored, ColorAlways).Append(log)
+	expected := "[0001/01/01 00:00:00] [agent.OplogTail.\x1b[33mwarn\x1b[0m] [oplog.go:88] Tail lagging\n"
+	if colored.String() != expected {
+		test.Errorf("Expected a yellow level. Received: %q", colored.String())
+	}
+
+	plain := new(bytes.Buffer)
+	NewConsoleAppender(plain, ColorAuto).Append(log)
+	if plain.String() != FormatLog(log) {
+		test.Errorf("Expected no color for a non-terminal. Received: %q", plain.String())
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		test.Fatalf("Cannot open `%v`. Err: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+
+	if NewConsoleAppender(devNull, ColorAuto).color {
+		test.Errorf("Expected no color for `%v`, which is not a terminal.", os.DevNull)
+	}
+}
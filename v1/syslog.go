@@ -0,0 +1,58 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package slogger
+
+import (
+	"bytes"
+	"log/syslog"
+)
+
+// SyslogAppender writes logs to a syslog daemon. The timestamp is left
+// to syslog; the rest of the message matches `FormatLog`.
+type SyslogAppender struct {
+	writer *syslog.Writer
+}
+
+// Connect to the syslog daemon at `raddr` over `network`, e.g. "udp"
+// and "logs.example.com:514". An empty `network` connects to the local
+// daemon. Logs are tagged with the program name.
+func NewSyslogAppender(network, raddr string, facility syslog.Priority) (*SyslogAppender, error) {
+	writer, err := syslog.Dial(network, raddr, facility|syslog.LOG_INFO, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyslogAppender{writer}, nil
+}
+
+// Custom levels map to the severity of the nearest built-in level
+// below them.
+func (self *SyslogAppender) Append(log *Log) error {
+	message := syslogMessage(log)
+	switch {
+	case log.Level >= ERROR:
+		return self.writer.Err(message)
+	case log.Level >= WARN:
+		return self.writer.Warning(message)
+	case log.Level >= INFO:
+		return self.writer.Info(message)
+	}
+
+	return self.writer.Debug(message)
+}
+
+func (self *SyslogAppender) Close() error {
+	return self.writer.Close()
+}
+
+// Stacktrace frames follow the message on their own lines, as in
+// `FormatLog`.
+func syslogMessage(log *Log) string {
+	buffer := bufferPool.Get().(*bytes.Buffer)
+	defer putBuffer(buffer)
+
+	buffer.Reset()
+	formatLogBodyTo(buffer, log, FormatLevel(log.Level))
+	return buffer.String()
+}
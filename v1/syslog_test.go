@@ -0,0 +1,65 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package slogger
+
+import (
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyslogAppender(test *testing.T) {
+	dir, err := ioutil.TempDir("", "slogger-syslog")
+	if err != nil {
+		test.Fatalf("Cannot create a temporary directory. Err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "socket")
+	listener, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		test.Fatalf("Cannot listen on `%v`. Err: %v", socket, err)
+	}
+	defer listener.Close()
+
+	appender, err := NewSyslogAppender("unixgram", socket, syslog.LOG_DAEMON)
+	if err != nil {
+		test.Fatalf("Cannot connect to `%v`. Err: %v", socket, err)
+	}
+	defer appender.Close()
+
+	err = appender.Append(&Log{
+		Prefix:     "agent.OplogTail",
+		Level:      WARN,
+		Filename:   "oplog.go",
+		Line:       88,
+		Stacktrace: []string{"at agent/oplog.go:88"},
+		messageFmt: "Tail started on RsId: `%v`",
+		args:       []interface{}{"backup_test"},
+	})
+	if err != nil {
+		test.Fatalf("Error appending. Err: %v", err)
+	}
+
+	datagram := make([]byte, 4096)
+	num, err := listener.Read(datagram)
+	if err != nil {
+		test.Fatalf("Error reading the datagram. Err: %v", err)
+	}
+
+	// LOG_DAEMON (3 << 3) | LOG_WARNING (4)
+	received := strings.TrimSpace(string(datagram[:num]))
+	if strings.HasPrefix(received, "<28>") == false {
+		test.Errorf("Expected a daemon.warning priority. Received: `%v`", received)
+	}
+
+	expected := ": [agent.OplogTail.warn] [oplog.go:88] Tail started on RsId: `backup_test`\n\tat agent/oplog.go:88"
+	if strings.HasSuffix(received, expected) == false {
+		test.Errorf("Unexpected syslog message. Received: `%v`", received)
+	}
+}
@@ -0,0 +1,13 @@
+package slogger
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Terminals are the file descriptors accepting the TCGETS ioctl.
+func isTerminalFd(fd uintptr) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package slogger
+
+// Terminal detection is not supported on this platform.
+func isTerminalFd(fd uintptr) bool {
+	return false
+}